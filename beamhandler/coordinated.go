@@ -0,0 +1,58 @@
+package beamhandler
+
+import (
+	"strings"
+
+	"github.com/gaemma/beam"
+	"github.com/gaemma/genid/coordination"
+)
+
+// CoordinatedHandler gates request handling behind leadership: only the
+// current leader serves requests directly, followers redirect clients to it
+// with a Redis Cluster-style MOVED reply so they can retry against the
+// right node.
+type CoordinatedHandler struct {
+	elector func() coordination.Elector
+	handler func() *DefaultHandler
+}
+
+// NewCoordinatedHandler creates a handler whose elector and handler are
+// resolved lazily on every request, since both change as leadership moves
+// between nodes.
+func NewCoordinatedHandler(elector func() coordination.Elector, handler func() *DefaultHandler) *CoordinatedHandler {
+	return &CoordinatedHandler{elector: elector, handler: handler}
+}
+
+func (h *CoordinatedHandler) Handle(req *beam.Request) (beam.Reply, error) {
+	if strings.ToUpper(req.CommandStr()) == "PING" {
+		return beam.NewSimpleStringsReply("PONG"), nil
+	}
+
+	elector := h.elector()
+	if elector == nil {
+		return beam.NewErrorsReply("coordinator not ready"), nil
+	}
+
+	if !elector.IsLeader() {
+		addr, err := elector.Leader()
+		if err != nil {
+			return beam.NewErrorsReply(err.Error()), nil
+		}
+		return beam.NewErrorsReply("MOVED " + addr), nil
+	}
+
+	handler := h.handler()
+	if handler == nil {
+		return beam.NewErrorsReply("leader not ready"), nil
+	}
+
+	// leadership can be lost between the check above and here, e.g. the
+	// lease expiring while handler() was being loaded; re-check right
+	// before dispatch to narrow the window in which a request can reach a
+	// generator this node no longer has exclusive ownership of.
+	if !elector.IsLeader() {
+		return beam.NewErrorsReply("leadership lost, retry"), nil
+	}
+
+	return handler.Handle(req)
+}
@@ -1,6 +1,7 @@
 package beamhandler
 
 import (
+	"sort"
 	"strconv"
 	"strings"
 
@@ -9,12 +10,16 @@ import (
 )
 
 type DefaultHandler struct {
-	engine generator.Generator
+	engine    generator.Generator
+	authToken string
 }
 
-func NewHandler(engine generator.Generator) *DefaultHandler {
+// NewHandler creates a handler backed by engine. authToken guards admin-only
+// commands like RESET; leave it empty to disable them entirely.
+func NewHandler(engine generator.Generator, authToken string) *DefaultHandler {
 	s := new(DefaultHandler)
 	s.engine = engine
+	s.authToken = authToken
 	return s
 }
 
@@ -45,8 +50,124 @@ func (s *DefaultHandler) Handle(req *beam.Request) (beam.Reply, error) {
 				resp = beam.NewSimpleStringsReply(strconv.FormatInt(id, 10))
 			}
 		}
+	case "INCRBY":
+		if req.Len() != 2 {
+			resp = beam.NewErrorsReply("invalid arguments")
+		} else if n, convErr := strconv.ParseInt(req.ArgStr(1), 10, 64); convErr != nil {
+			resp = beam.NewErrorsReply("invalid count")
+		} else {
+			start, _, err := s.engine.NextN(req.ArgStr(0), n)
+			if err != nil {
+				resp = beam.NewErrorsReply(err.Error())
+			} else {
+				resp = beam.NewIntegersReply(int(start))
+			}
+		}
+	case "MGET":
+		if req.Len() == 0 {
+			resp = beam.NewErrorsReply("invalid arguments")
+		} else {
+			replies := make([]beam.Reply, req.Len())
+			for i := 0; i < req.Len(); i++ {
+				id, err := s.engine.Current(req.ArgStr(i))
+				if err != nil {
+					replies[i] = beam.NewErrorsReply(err.Error())
+				} else {
+					replies[i] = beam.NewSimpleStringsReply(strconv.FormatInt(id, 10))
+				}
+			}
+			resp = beam.NewArraysReply(replies)
+		}
+	case "MINCR":
+		if req.Len() == 0 {
+			resp = beam.NewErrorsReply("invalid arguments")
+		} else {
+			replies := make([]beam.Reply, req.Len())
+			for i := 0; i < req.Len(); i++ {
+				id, err := s.engine.Next(req.ArgStr(i))
+				if err != nil {
+					replies[i] = beam.NewErrorsReply(err.Error())
+				} else {
+					replies[i] = beam.NewIntegersReply(int(id))
+				}
+			}
+			resp = beam.NewArraysReply(replies)
+		}
+	case "RESET":
+		if req.Len() != 3 {
+			resp = beam.NewErrorsReply("invalid arguments")
+		} else if !s.authorized(req.ArgStr(2)) {
+			resp = beam.NewErrorsReply("unauthorized")
+		} else if value, convErr := strconv.ParseInt(req.ArgStr(1), 10, 64); convErr != nil {
+			resp = beam.NewErrorsReply("invalid value")
+		} else if err := s.engine.Reset(req.ArgStr(0), value); err != nil {
+			resp = beam.NewErrorsReply(err.Error())
+		} else {
+			resp = beam.NewSimpleStringsReply("OK")
+		}
+	case "KEYS":
+		if req.Len() != 0 {
+			resp = beam.NewErrorsReply("invalid arguments")
+		} else {
+			keys := s.engine.Keys()
+			replies := make([]beam.Reply, len(keys))
+			for i, key := range keys {
+				replies[i] = beam.NewSimpleStringsReply(key)
+			}
+			resp = beam.NewArraysReply(replies)
+		}
+	case "ENABLE":
+		if req.Len() != 1 {
+			resp = beam.NewErrorsReply("invalid arguments")
+		} else if err := s.engine.Enable(req.ArgStr(0)); err != nil {
+			resp = beam.NewErrorsReply(err.Error())
+		} else {
+			resp = beam.NewSimpleStringsReply("OK")
+		}
+	case "DISABLE":
+		if req.Len() != 1 {
+			resp = beam.NewErrorsReply("invalid arguments")
+		} else if err := s.engine.Disable(req.ArgStr(0)); err != nil {
+			resp = beam.NewErrorsReply(err.Error())
+		} else {
+			resp = beam.NewSimpleStringsReply("OK")
+		}
+	case "STATUS":
+		reporter, ok := s.engine.(generator.StatusReporter)
+		if !ok {
+			resp = beam.NewErrorsReply("status not supported")
+		} else {
+			status, err := reporter.Status()
+			if err != nil {
+				resp = beam.NewErrorsReply(err.Error())
+			} else {
+				resp = beam.NewSimpleStringsReply(formatStatus(status))
+			}
+		}
 	default:
 		resp = beam.NewErrorsReply("unsupported method.")
 	}
 	return resp, nil
 }
+
+// authorized reports whether token matches the configured admin auth token.
+// An empty configured token disables admin commands entirely.
+func (s *DefaultHandler) authorized(token string) bool {
+	return s.authToken != "" && token == s.authToken
+}
+
+// formatStatus renders a status map as sorted "key=value" pairs so the
+// reply is stable across calls.
+func formatStatus(status map[string]string) string {
+	keys := make([]string, 0, len(status))
+	for k := range status {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]string, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, k+"="+status[k])
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gaemma/genid/beamhandler"
+	"github.com/gaemma/genid/coordination"
+	"github.com/gaemma/genid/generator"
+)
+
+// coordinatedRunner keeps exactly one node of a genid cluster holding the
+// backend generator open at a time: it campaigns for leadership, opens the
+// generator only once elected, and closes it again as soon as leadership is
+// lost so followers never burn their own [cur, max] window.
+type coordinatedRunner struct {
+	config         Config
+	meta           toml.MetaData
+	primitive      toml.Primitive
+	driver         string
+	coordPrimitive toml.Primitive
+
+	elector atomic.Value // coordination.Elector
+	handler atomic.Value // *beamhandler.DefaultHandler
+}
+
+func newCoordinatedRunner(config Config, meta toml.MetaData, primitive toml.Primitive, driver string, coordPrimitive toml.Primitive) *coordinatedRunner {
+	return &coordinatedRunner{
+		config:         config,
+		meta:           meta,
+		primitive:      primitive,
+		driver:         driver,
+		coordPrimitive: coordPrimitive,
+	}
+}
+
+func (r *coordinatedRunner) currentElector() coordination.Elector {
+	elector, _ := r.elector.Load().(coordination.Elector)
+	return elector
+}
+
+func (r *coordinatedRunner) currentHandler() *beamhandler.DefaultHandler {
+	handler, _ := r.handler.Load().(*beamhandler.DefaultHandler)
+	return handler
+}
+
+// shutdown releases this node's lease, if any, so a waiting follower can be
+// promoted immediately instead of waiting for the lease to time out.
+func (r *coordinatedRunner) shutdown() {
+	if elector := r.currentElector(); elector != nil {
+		if err := elector.Resign(); err != nil {
+			logger.Warning("fail to resign leadership: %s", err.Error())
+		}
+	}
+}
+
+// run campaigns for leadership forever. Each cycle it creates a fresh
+// Elector, blocks until it wins the campaign, opens the generator, and waits
+// for the lease to be lost before closing the generator and starting over.
+func (r *coordinatedRunner) run() {
+	for {
+		elector, err := coordination.New(r.driver, r.meta, r.coordPrimitive, r.config.Listen)
+		if err != nil {
+			logger.Warning("fail to create %s elector: %s", r.driver, err.Error())
+			time.Sleep(time.Second)
+			continue
+		}
+		r.elector.Store(elector)
+
+		if err := elector.Campaign(context.Background()); err != nil {
+			logger.Warning("campaign for leadership failed: %s", err.Error())
+			elector.Close()
+			time.Sleep(time.Second)
+			continue
+		}
+		logger.Info("acquired leadership, opening %s generator.", r.config.Engine)
+
+		gen, err := generator.New(r.config.Engine, r.meta, r.primitive, logger)
+		if err != nil {
+			logger.Warning("fail to open generator as leader: %s", err.Error())
+			elector.Resign()
+			elector.Close()
+			continue
+		}
+		gen.EnableKeys(r.config.Keys)
+		r.handler.Store(beamhandler.NewHandler(gen, r.config.AuthToken))
+
+		<-elector.Done()
+
+		logger.Info("lost leadership, closing %s generator.", r.config.Engine)
+		r.handler.Store((*beamhandler.DefaultHandler)(nil))
+		gen.Close()
+		elector.Close()
+	}
+}
@@ -31,15 +31,14 @@ type Config struct {
 	PIDFile     string `toml:"pidfile"`
 	Listen      string
 	Engine      string
-	Step        int64
 	Keys        []string
+	AuthToken   string   `toml:"auth_token"`
+	Coordinator string   `toml:"coordinator"`
 	RWTimeout   duration `toml:"rw_timeout"`
 	IdleTimeout duration `toml:"idle_timeout"`
 
-	Mysql struct {
-		DSN       string
-		TableName string
-	}
+	Backend      map[string]toml.Primitive `toml:"backend"`
+	Coordination map[string]toml.Primitive `toml:"coordination"`
 }
 
 var logger = logging.NewSimpleLogger()
@@ -68,6 +67,21 @@ func main() {
 			Usage:  "Run the server",
 			Action: commandRun,
 		},
+		{
+			Name:  "migrate",
+			Usage: "Migrate the generator's step or schema online",
+			Flags: []cli.Flag{
+				cli.Int64Flag{
+					Name:  "step",
+					Usage: "change the effective step to `N` for every configured key",
+				},
+				cli.BoolFlag{
+					Name:  "widen-value",
+					Usage: "widen the value/step columns from INT to BIGINT UNSIGNED",
+				},
+			},
+			Action: commandMigrate,
+		},
 	}
 
 	err := app.Run(os.Args)
@@ -77,7 +91,7 @@ func main() {
 	}
 }
 
-func handleSignals(s *beam.Server, config Config) {
+func handleSignals(s *beam.Server, config Config, onShutdown func()) {
 	ch := make(chan os.Signal)
 	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
@@ -85,6 +99,9 @@ func handleSignals(s *beam.Server, config Config) {
 		switch sig {
 		case syscall.SIGINT, syscall.SIGTERM:
 			logger.Info("receive signal: %s.", sig)
+			if onShutdown != nil {
+				onShutdown()
+			}
 			err := s.Close()
 			if len(config.PIDFile) > 0 {
 				logger.Info("remove PIDFILE: %s.", config.PIDFile)
@@ -100,18 +117,34 @@ func handleSignals(s *beam.Server, config Config) {
 	}()
 }
 
-func parseConfig(c *cli.Context) (config Config, err error) {
+func parseConfig(c *cli.Context) (config Config, meta toml.MetaData, err error) {
 	path := c.GlobalString("c")
 	if len(path) == 0 {
 		err = cli.NewExitError("config path should be specified.", 10)
 		return
 	}
-	_, err = toml.DecodeFile(path, &config)
-	return config, err
+	meta, err = toml.DecodeFile(path, &config)
+	return
+}
+
+func backendPrimitive(config Config) (primitive toml.Primitive, err error) {
+	primitive, exist := config.Backend[config.Engine]
+	if !exist {
+		err = cli.NewExitError(fmt.Sprintf("unknown backend: %s", config.Engine), 10)
+	}
+	return
+}
+
+func coordinationPrimitive(config Config) (primitive toml.Primitive, err error) {
+	primitive, exist := config.Coordination[config.Coordinator]
+	if !exist {
+		err = cli.NewExitError(fmt.Sprintf("unknown coordination driver: %s", config.Coordinator), 10)
+	}
+	return
 }
 
 func commandRun(c *cli.Context) error {
-	config, err := parseConfig(c)
+	config, meta, err := parseConfig(c)
 	if err != nil {
 		return err
 	}
@@ -126,28 +159,41 @@ func commandRun(c *cli.Context) error {
 		logger.Info("create pidfile \"%s\" with PID \"%d\".", config.PIDFile, pid)
 	}
 
-	if config.Engine != "mysql" {
-		return cli.NewExitError("only mysql engine supported.", 10)
-	}
-
-	mysqlConfig := generator.MysqlConfig{
-		Dsn:       config.Mysql.DSN,
-		TableName: config.Mysql.TableName,
-	}
-	gen, err := generator.NewMysqlGenerator(mysqlConfig, config.Step, logger)
+	primitive, err := backendPrimitive(config)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 10)
+		return err
 	}
-	defer gen.Close()
-	gen.EnableKeys(config.Keys)
 
 	serverConfig := beam.Config{
 		Logger: logging.NewSimpleLogger(),
 		Addr:   config.Listen,
 	}
-	server := beam.NewServer(beamhandler.NewHandler(gen), serverConfig)
 
-	handleSignals(server, config)
+	var handler beam.Handler
+	var onShutdown func()
+
+	if len(config.Coordinator) == 0 {
+		gen, err := generator.New(config.Engine, meta, primitive, logger)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 10)
+		}
+		defer gen.Close()
+		gen.EnableKeys(config.Keys)
+		handler = beamhandler.NewHandler(gen, config.AuthToken)
+	} else {
+		coordPrimitive, err := coordinationPrimitive(config)
+		if err != nil {
+			return err
+		}
+		runner := newCoordinatedRunner(config, meta, primitive, config.Coordinator, coordPrimitive)
+		go runner.run()
+		handler = beamhandler.NewCoordinatedHandler(runner.currentElector, runner.currentHandler)
+		onShutdown = runner.shutdown
+	}
+
+	server := beam.NewServer(handler, serverConfig)
+
+	handleSignals(server, config, onShutdown)
 
 	err = server.Serve()
 	if err != nil {
@@ -162,26 +208,65 @@ func commandRun(c *cli.Context) error {
 }
 
 func commandInit(c *cli.Context) error {
-	config, err := parseConfig(c)
+	config, meta, err := parseConfig(c)
 	if err != nil {
 		return err
 	}
 
 	logger.Info("load configuration %v", config)
 
-	if config.Engine != "mysql" {
-		return cli.NewExitError("only mysql engine supported.", 10)
+	primitive, err := backendPrimitive(config)
+	if err != nil {
+		return err
+	}
+
+	err = generator.Init(config.Engine, meta, primitive)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 10)
 	}
+	return nil
+}
 
-	mysqlConfig := generator.MysqlConfig{
-		Dsn:       config.Mysql.DSN,
-		TableName: config.Mysql.TableName,
+func commandMigrate(c *cli.Context) error {
+	config, meta, err := parseConfig(c)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("load configuration %v", config)
+
+	if config.Engine != "mysql" {
+		return cli.NewExitError(fmt.Sprintf("migrate is only supported for the mysql backend, got: %s", config.Engine), 10)
 	}
 
-	err = generator.InitMysqlGenerator(mysqlConfig)
+	primitive, err := backendPrimitive(config)
 	if err != nil {
+		return err
+	}
+
+	var mysqlConfig generator.MysqlConfig
+	if err := meta.PrimitiveDecode(primitive, &mysqlConfig); err != nil {
 		return cli.NewExitError(err.Error(), 10)
 	}
+
+	widen := c.Bool("widen-value")
+	step := c.Int64("step")
+	if !widen && step <= 0 {
+		return cli.NewExitError("specify --step or --widen-value.", 10)
+	}
+
+	if widen {
+		if err := generator.MigrateWidenValue(mysqlConfig); err != nil {
+			return cli.NewExitError(err.Error(), 10)
+		}
+	}
+
+	if step > 0 {
+		if err := generator.MigrateStep(mysqlConfig, config.Keys, step); err != nil {
+			return cli.NewExitError(err.Error(), 10)
+		}
+	}
+
 	return nil
 }
 
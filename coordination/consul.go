@@ -0,0 +1,183 @@
+package coordination
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+// ConsulConfig contains the parameters needed to campaign for leadership
+// through a consul session lock.
+type ConsulConfig struct {
+	Addr string
+	Key  string
+	TTL  int
+}
+
+func init() {
+	Register("consul", consulFactory)
+}
+
+func consulFactory(meta toml.MetaData, primitive toml.Primitive, id string) (Elector, error) {
+	var config ConsulConfig
+	if err := meta.PrimitiveDecode(primitive, &config); err != nil {
+		return nil, err
+	}
+	return NewConsulElector(config, id)
+}
+
+// NewConsulElector creates an Elector backed by a consul session lock.
+func NewConsulElector(config ConsulConfig, id string) (elector Elector, err error) {
+	if config.TTL <= 0 {
+		config.TTL = 10
+	}
+	if len(config.Key) == 0 {
+		config.Key = "genid/leader"
+	}
+
+	client, err := consulapi.NewClient(&consulapi.Config{Address: config.Addr})
+	if err != nil {
+		return
+	}
+
+	elector = &consulElector{
+		client: client,
+		key:    config.Key,
+		ttl:    time.Duration(config.TTL) * time.Second,
+		id:     id,
+	}
+	return
+}
+
+// lease tracks a single acquired consul session, including the once-guarded
+// channel that signals its loss so Resign and a failed renew never race to
+// close the same channel twice.
+type lease struct {
+	sessionID string
+	done      chan struct{}
+	once      sync.Once
+}
+
+func (l *lease) close() {
+	l.once.Do(func() { close(l.done) })
+}
+
+type consulElector struct {
+	mutex    sync.RWMutex
+	client   *consulapi.Client
+	key      string
+	ttl      time.Duration
+	id       string
+	isLeader bool
+	lease    *lease
+}
+
+func (e *consulElector) Campaign(ctx context.Context) error {
+	sessionID, _, err := e.client.Session().Create(&consulapi.SessionEntry{
+		TTL:      e.ttl.String(),
+		Behavior: consulapi.SessionBehaviorRelease,
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	pair := &consulapi.KVPair{Key: e.key, Value: []byte(e.id), Session: sessionID}
+	for {
+		acquired, _, err := e.client.KV().Acquire(pair, nil)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			l := &lease{sessionID: sessionID, done: make(chan struct{})}
+			e.mutex.Lock()
+			e.isLeader = true
+			e.lease = l
+			e.mutex.Unlock()
+			go e.renew(l)
+			return nil
+		}
+
+		// a follower can wait here far longer than ttl; without renewing,
+		// consul invalidates sessionID once its TTL lapses and every
+		// subsequent Acquire fails forever, so this node could never take
+		// over once the leader dies.
+		if _, _, err := e.client.Session().Renew(sessionID, nil); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.ttl / 2):
+		}
+	}
+}
+
+// renew keeps l's session alive roughly twice per TTL. It gives up and
+// closes l.done as soon as the session can no longer be renewed, e.g.
+// because it expired or consul reclaimed the lock.
+func (e *consulElector) renew(l *lease) {
+	ticker := time.NewTicker(e.ttl / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, _, err := e.client.Session().Renew(l.sessionID, nil); err != nil {
+			e.mutex.Lock()
+			if e.lease == l {
+				e.isLeader = false
+			}
+			e.mutex.Unlock()
+			l.close()
+			return
+		}
+	}
+}
+
+func (e *consulElector) IsLeader() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.isLeader
+}
+
+func (e *consulElector) Leader() (string, error) {
+	pair, _, err := e.client.KV().Get(e.key, nil)
+	if err != nil {
+		return "", err
+	}
+	if pair == nil || len(pair.Value) == 0 {
+		return "", fmt.Errorf("no leader elected")
+	}
+	return string(pair.Value), nil
+}
+
+func (e *consulElector) Resign() error {
+	e.mutex.Lock()
+	l := e.lease
+	e.isLeader = false
+	e.lease = nil
+	e.mutex.Unlock()
+
+	if l == nil {
+		return nil
+	}
+	pair := &consulapi.KVPair{Key: e.key, Session: l.sessionID}
+	_, _, err := e.client.KV().Release(pair, nil)
+	l.close()
+	return err
+}
+
+func (e *consulElector) Done() <-chan struct{} {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if e.lease == nil {
+		return nil
+	}
+	return e.lease.done
+}
+
+func (e *consulElector) Close() error {
+	return e.Resign()
+}
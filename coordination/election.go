@@ -0,0 +1,63 @@
+// Package coordination provides optional leader election for running
+// several genid nodes against the same backend without fragmenting the id
+// space across disjoint [cur, max] windows.
+package coordination
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+)
+
+// An Elector campaigns for and tracks leadership of a shared lease. Only the
+// current leader should open the backend generator and serve INCR; the rest
+// should redirect clients to it.
+type Elector interface {
+	io.Closer
+	// Campaign blocks until this node becomes leader or ctx is done.
+	Campaign(ctx context.Context) error
+	// IsLeader reports whether this node currently holds leadership.
+	IsLeader() bool
+	// Leader returns the address advertised by the current leader.
+	Leader() (string, error)
+	// Resign releases leadership immediately, e.g. on SIGTERM, so a new
+	// leader can be elected without waiting for the lease to expire.
+	Resign() error
+	// Done is closed when this node's leadership lease is lost. The consul
+	// driver closes it on Resign or when its session can no longer be
+	// renewed; the etcd driver closes it only when its session ends (lease
+	// expiry or Close), not on Resign.
+	Done() <-chan struct{}
+}
+
+// Factory builds an Elector from its driver-specific TOML section, e.g. the
+// `[coordination.etcd]` table. id is the address this node advertises to
+// followers once it becomes leader.
+type Factory func(meta toml.MetaData, primitive toml.Primitive, id string) (Elector, error)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]Factory)
+)
+
+// Register makes a coordination driver available under name. It is meant to
+// be called from the init function of the package implementing the driver.
+func Register(name string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry[name] = factory
+}
+
+// New creates the Elector registered under name.
+func New(name string, meta toml.MetaData, primitive toml.Primitive, id string) (Elector, error) {
+	registryMutex.RLock()
+	factory, exist := registry[name]
+	registryMutex.RUnlock()
+	if !exist {
+		return nil, fmt.Errorf("unknown coordination driver: %s", name)
+	}
+	return factory(meta, primitive, id)
+}
@@ -0,0 +1,136 @@
+package coordination
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// EtcdConfig contains the parameters needed to campaign for leadership
+// through an etcd cluster.
+type EtcdConfig struct {
+	Endpoints []string
+	Prefix    string
+	TTL       int
+}
+
+func init() {
+	Register("etcd", etcdFactory)
+}
+
+func etcdFactory(meta toml.MetaData, primitive toml.Primitive, id string) (Elector, error) {
+	var config EtcdConfig
+	if err := meta.PrimitiveDecode(primitive, &config); err != nil {
+		return nil, err
+	}
+	return NewEtcdElector(config, id)
+}
+
+// NewEtcdElector creates an Elector backed by etcd's concurrency package.
+func NewEtcdElector(config EtcdConfig, id string) (elector Elector, err error) {
+	if config.TTL <= 0 {
+		config.TTL = 10
+	}
+	if len(config.Prefix) == 0 {
+		config.Prefix = "/genid/leader"
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   config.Endpoints,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return
+	}
+
+	session, err := concurrency.NewSession(client, concurrency.WithTTL(config.TTL))
+	if err != nil {
+		client.Close()
+		return
+	}
+
+	e := &etcdElector{
+		client:   client,
+		session:  session,
+		election: concurrency.NewElection(session, config.Prefix),
+		id:       id,
+	}
+	go e.watchSession()
+	elector = e
+	return
+}
+
+type etcdElector struct {
+	mutex    sync.RWMutex
+	client   *clientv3.Client
+	session  *concurrency.Session
+	election *concurrency.Election
+	id       string
+	isLeader bool
+}
+
+func (e *etcdElector) Campaign(ctx context.Context) error {
+	if err := e.election.Campaign(ctx, e.id); err != nil {
+		return err
+	}
+	e.mutex.Lock()
+	e.isLeader = true
+	e.mutex.Unlock()
+	return nil
+}
+
+func (e *etcdElector) IsLeader() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.isLeader
+}
+
+func (e *etcdElector) Leader() (string, error) {
+	resp, err := e.election.Leader(context.Background())
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", fmt.Errorf("no leader elected")
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+func (e *etcdElector) Resign() error {
+	e.mutex.Lock()
+	e.isLeader = false
+	e.mutex.Unlock()
+	return e.election.Resign(context.Background())
+}
+
+func (e *etcdElector) Done() <-chan struct{} {
+	return e.session.Done()
+}
+
+// watchSession resets isLeader as soon as the etcd session backing our
+// lease is gone, whether from Close or the lease expiring/losing its
+// connection (Resign resets isLeader itself and leaves the session open).
+// Without this, IsLeader() keeps reporting true for however
+// long the caller takes to notice Done() and tear the handler down,
+// letting a deposed leader keep serving INCR in a window that overlaps a
+// newly elected leader's.
+func (e *etcdElector) watchSession() {
+	<-e.session.Done()
+	e.mutex.Lock()
+	e.isLeader = false
+	e.mutex.Unlock()
+}
+
+func (e *etcdElector) Close() error {
+	_ = e.Resign()
+	if err := e.session.Close(); err != nil {
+		e.client.Close()
+		return err
+	}
+	return e.client.Close()
+}
@@ -6,28 +6,96 @@ import (
 	"sync"
 	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/gaemma/logging"
 	_ "github.com/go-sql-driver/mysql"
 )
 
 const (
-	selectSQL      = "SELECT `value` FROM %s WHERE `key` = ? FOR UPDATE"
+	selectSQL      = "SELECT `value`, `step` FROM %s WHERE `key` = ? FOR UPDATE"
 	insertSQL      = "INSERT INTO %s (`key`, `value`, `last_mod_at`) values (?, ?, ?)"
 	updateSQL      = "UPDATE %s SET `value` = ?, `last_mod_at` = ? WHERE `key` = ?"
 	createTableSQL = "CREATE TABLE %s (\n" +
 		"	`id` INT UNSIGNED NOT NULL AUTO_INCREMENT,\n" +
 		"	`key` VARCHAR(32) NOT NULL,\n" +
 		"	`value` INT UNSIGNED NOT NULL,\n" +
+		"	`step` INT UNSIGNED NOT NULL DEFAULT 0,\n" +
+		"	`schema_version` INT UNSIGNED NOT NULL DEFAULT 1,\n" +
 		"	`last_mod_at` INT UNSIGNED NOT NULL,\n" +
 		"	PRIMARY KEY (`id`),\n" +
 		"	UNIQUE KEY `key` (`key`)\n" +
 		") ENGINE=InnoDB DEFAULT CHARSET=utf8"
 )
 
+// currentSchemaVersion is the schema_version InitMysqlGenerator's table
+// starts at. Migrate refuses to run against a table it doesn't recognize.
+const currentSchemaVersion = 1
+
+// upgradeToV1 adds the step and schema_version columns to a table created
+// by a pre-series createTableSQL, which had neither. It is idempotent, so
+// both NewMysqlGenerator and the migrate commands can call it unconditionally
+// every time they open the table.
+func upgradeToV1(db *sql.DB, tableName string) error {
+	for _, column := range []struct {
+		name string
+		ddl  string
+	}{
+		{"step", "`step` INT UNSIGNED NOT NULL DEFAULT 0"},
+		{"schema_version", "`schema_version` INT UNSIGNED NOT NULL DEFAULT 1"},
+	} {
+		exists, err := columnExists(db, tableName, column.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tableName, column.ddl)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func columnExists(db *sql.DB, tableName, column string) (exists bool, err error) {
+	var cnt int
+	err = db.QueryRow(
+		"SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?",
+		tableName, column,
+	).Scan(&cnt)
+	exists = cnt > 0
+	return
+}
+
 // MysqlConfig contains the parameters needed by the generator.
 type MysqlConfig struct {
 	Dsn       string
 	TableName string
+	Step      int64
+	Replica   *MysqlReplicaConfig
+}
+
+func init() {
+	Register("mysql", mysqlFactory, mysqlInitializer)
+}
+
+func mysqlFactory(meta toml.MetaData, primitive toml.Primitive, logger logging.Logger) (Generator, error) {
+	var config MysqlConfig
+	if err := meta.PrimitiveDecode(primitive, &config); err != nil {
+		return nil, err
+	}
+	if config.Replica != nil {
+		return newMysqlReplicaGenerator(config, logger)
+	}
+	return NewMysqlGenerator(config, logger)
+}
+
+func mysqlInitializer(meta toml.MetaData, primitive toml.Primitive) error {
+	var config MysqlConfig
+	if err := meta.PrimitiveDecode(primitive, &config); err != nil {
+		return err
+	}
+	return InitMysqlGenerator(config)
 }
 
 // InitMysqlGenerator initializes the table needed.
@@ -41,7 +109,7 @@ func InitMysqlGenerator(config MysqlConfig) (err error) {
 }
 
 // NewMysqlGenerator creates a mysql id generator.
-func NewMysqlGenerator(config MysqlConfig, step int64, logger logging.Logger) (generator Generator, err error) {
+func NewMysqlGenerator(config MysqlConfig, logger logging.Logger) (generator Generator, err error) {
 	db, err := sql.Open("mysql", config.Dsn)
 	if err != nil {
 		return
@@ -51,11 +119,15 @@ func NewMysqlGenerator(config MysqlConfig, step int64, logger logging.Logger) (g
 		return
 	}
 
+	if err = upgradeToV1(db, config.TableName); err != nil {
+		return
+	}
+
 	g := new(mysqlGenerator)
 	g.sourceMap = make(map[string]*mysqlRowBasedEngine)
 	g.db = db
 	g.config = config
-	g.skip = step
+	g.skip = config.Step
 	if logger == nil {
 		logger = logging.NewNopLogger()
 	}
@@ -104,6 +176,50 @@ func (m *mysqlGenerator) Current(key string) (id int64, err error) {
 	return engine.current()
 }
 
+func (m *mysqlGenerator) NextN(key string, n int64) (start, end int64, err error) {
+	engine, err := m.rowBasedEngine(key)
+	if err != nil {
+		return
+	}
+	return engine.nextN(n)
+}
+
+func (m *mysqlGenerator) Reset(key string, value int64) error {
+	engine, err := m.rowBasedEngine(key)
+	if err != nil {
+		return err
+	}
+	return engine.reset(value)
+}
+
+func (m *mysqlGenerator) Keys() []string {
+	m.RLock()
+	defer m.RUnlock()
+	keys := make([]string, 0, len(m.sourceMap))
+	for key := range m.sourceMap {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (m *mysqlGenerator) Enable(key string) (err error) {
+	engine, err := newMysqlRowBasedEngine(m, key, m.skip, m.logger)
+	if err != nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.sourceMap[key] = engine
+	return
+}
+
+func (m *mysqlGenerator) Disable(key string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.sourceMap, key)
+	return nil
+}
+
 func (m *mysqlGenerator) Close() error {
 	return m.db.Close()
 }
@@ -177,6 +293,46 @@ func (m *mysqlRowBasedEngine) current() (int64, error) {
 	return m.cur, nil
 }
 
+// nextN allocates n consecutive ids in one go, bumping the window by n*skip
+// when the current window can't satisfy the request, so it still only takes
+// a single round trip to MySQL in the common case.
+func (m *mysqlRowBasedEngine) nextN(n int64) (start, end int64, err error) {
+	if n <= 0 {
+		err = fmt.Errorf("invalid count: %d", n)
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.max-m.cur < n {
+		m.logger.Info("increase counter for key: \"%s\"", m.key)
+		m.cur, m.max, err = m.increase(n * m.skip)
+		if err != nil {
+			return
+		}
+	}
+	start = m.cur + 1
+	end = m.cur + n
+	m.cur = end
+	return
+}
+
+func (m *mysqlRowBasedEngine) reset(value int64) (err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	// the stored value is the last id handed out, so the next id is value.
+	res, err := m.generator.db.Exec(m.updateSQL, value-1, time.Now().Unix(), m.key)
+	if err != nil {
+		return
+	}
+	_, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+	m.cur = value - 1
+	m.max = value - 1
+	return
+}
+
 func (m *mysqlRowBasedEngine) increase(delta int64) (cur, max int64, err error) {
 	defer func() {
 		if err == nil {
@@ -197,7 +353,8 @@ func (m *mysqlRowBasedEngine) increase(delta int64) (cur, max int64, err error)
 	if err != nil {
 		return
 	}
-	err = m.generator.db.QueryRow(m.selectSQL, m.key).Scan(&cur)
+	var step int64
+	err = m.generator.db.QueryRow(m.selectSQL, m.key).Scan(&cur, &step)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			var res sql.Result
@@ -216,6 +373,12 @@ func (m *mysqlRowBasedEngine) increase(delta int64) (cur, max int64, err error)
 		return
 	}
 
+	// a genid migrate --step run writes an override into the step column;
+	// pick it up here so the next refill after this one uses it.
+	if step > 0 {
+		m.skip = step
+	}
+
 	max = cur + delta
 	res, err := m.generator.db.Exec(m.updateSQL, max, time.Now().Unix(), m.key)
 	if err != nil {
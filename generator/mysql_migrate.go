@@ -0,0 +1,221 @@
+package generator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// widenedSchemaVersion is the schema_version MigrateWidenValue leaves a
+// table at once `value`/`step` have been widened to BIGINT UNSIGNED.
+const widenedSchemaVersion = 2
+
+const createWideTableSQL = "CREATE TABLE %s (\n" +
+	"	`id` INT UNSIGNED NOT NULL AUTO_INCREMENT,\n" +
+	"	`key` VARCHAR(32) NOT NULL,\n" +
+	"	`value` BIGINT UNSIGNED NOT NULL,\n" +
+	"	`step` BIGINT UNSIGNED NOT NULL DEFAULT 0,\n" +
+	"	`schema_version` INT UNSIGNED NOT NULL DEFAULT 1,\n" +
+	"	`last_mod_at` INT UNSIGNED NOT NULL,\n" +
+	"	PRIMARY KEY (`id`),\n" +
+	"	UNIQUE KEY `key` (`key`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8"
+
+// MigrateStep bumps every key's value forward to the next multiple of
+// newStep and records newStep in the row's step column, so a window
+// allocated under the old step can never overlap one allocated under the
+// new step, and running servers pick up newStep the next time
+// mysqlRowBasedEngine.increase refills their local window.
+func MigrateStep(config MysqlConfig, keys []string, newStep int64) (err error) {
+	if newStep <= 0 {
+		return fmt.Errorf("invalid step: %d", newStep)
+	}
+
+	db, err := sql.Open("mysql", config.Dsn)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	if err = upgradeToV1(db, config.TableName); err != nil {
+		return
+	}
+
+	// a previously widened table only has value/step in BIGINT UNSIGNED
+	// instead of INT UNSIGNED; migrateKeyStep reads and writes both columns
+	// generically, so either schema version is safe to run this against.
+	if err = checkSchemaVersion(db, config.TableName, currentSchemaVersion, widenedSchemaVersion); err != nil {
+		return
+	}
+
+	for _, key := range keys {
+		if err = migrateKeyStep(db, config.TableName, key, newStep); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func migrateKeyStep(db *sql.DB, tableName, key string, newStep int64) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			newErr := tx.Rollback()
+			if newErr != nil {
+				err = newErr
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var value int64
+	selectSQL := fmt.Sprintf("SELECT `value` FROM %s WHERE `key` = ? FOR UPDATE", tableName)
+	err = tx.QueryRow(selectSQL, key).Scan(&value)
+	if err != nil {
+		return
+	}
+
+	// round up to the next multiple of newStep, so any window already
+	// handed out under the old step is fully behind the new floor.
+	safeValue := ((value / newStep) + 1) * newStep
+
+	updateSQL := fmt.Sprintf("UPDATE %s SET `value` = ?, `step` = ?, `last_mod_at` = ? WHERE `key` = ?", tableName)
+	_, err = tx.Exec(updateSQL, safeValue, newStep, time.Now().Unix(), key)
+	return
+}
+
+// MigrateWidenValue widens the `value` and `step` columns from INT UNSIGNED
+// to BIGINT UNSIGNED without downtime: it builds a `<table>_new` shadow
+// table with the wider schema, then copies every row across and swaps the
+// two tables with RENAME TABLE while holding an explicit LOCK TABLES WRITE
+// on both for the whole copy+rename, so no writer can race the swap.
+func MigrateWidenValue(config MysqlConfig) (err error) {
+	db, err := sql.Open("mysql", config.Dsn)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	if err = upgradeToV1(db, config.TableName); err != nil {
+		return
+	}
+
+	if err = checkSchemaVersion(db, config.TableName, currentSchemaVersion); err != nil {
+		return
+	}
+
+	shadowTable := config.TableName + "_new"
+	if _, err = db.Exec(fmt.Sprintf(createWideTableSQL, shadowTable)); err != nil {
+		return
+	}
+
+	oldTable := config.TableName + "_old"
+	return copyAndSwapForWiden(db, config.TableName, shadowTable, oldTable)
+}
+
+// copyAndSwapForWiden copies every row into shadowTable and renames
+// tableName to oldTable/shadowTable to tableName, all on a single dedicated
+// connection held under LOCK TABLES WRITE for both tables.
+//
+// A plain transaction can't do this safely: RENAME TABLE is DDL, and DDL
+// performs an implicit COMMIT before it runs, which would release the
+// FOR UPDATE locks taken by the copy's SELECT before the rename itself
+// executes. In that gap a concurrent `increase` could grab the row lock,
+// advance `value` on the original table, and have that write silently
+// discarded once it becomes `<table>_old`. LOCK TABLES instead blocks every
+// other session's reads and writes against tableName for as long as this
+// connection holds it, so nothing can land there between the copy and the
+// swap.
+func copyAndSwapForWiden(db *sql.DB, tableName, shadowTable, oldTable string) (err error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	lockSQL := fmt.Sprintf("LOCK TABLES %s WRITE, %s WRITE", tableName, shadowTable)
+	if _, err = conn.ExecContext(ctx, lockSQL); err != nil {
+		return
+	}
+	defer func() {
+		_, unlockErr := conn.ExecContext(ctx, "UNLOCK TABLES")
+		if err == nil {
+			err = unlockErr
+		}
+	}()
+
+	selectSQL := fmt.Sprintf("SELECT `key`, `value`, `step`, `last_mod_at` FROM %s", tableName)
+	rows, err := conn.QueryContext(ctx, selectSQL)
+	if err != nil {
+		return
+	}
+
+	type row struct {
+		key       string
+		value     int64
+		step      int64
+		lastModAt int64
+	}
+	var copied []row
+	for rows.Next() {
+		var r row
+		if err = rows.Scan(&r.key, &r.value, &r.step, &r.lastModAt); err != nil {
+			rows.Close()
+			return
+		}
+		copied = append(copied, r)
+	}
+	if err = rows.Err(); err != nil {
+		rows.Close()
+		return
+	}
+	rows.Close()
+
+	insertSQL := fmt.Sprintf("INSERT INTO %s (`key`, `value`, `step`, `schema_version`, `last_mod_at`) VALUES (?, ?, ?, ?, ?)", shadowTable)
+	for _, r := range copied {
+		if _, err = conn.ExecContext(ctx, insertSQL, r.key, r.value, r.step, widenedSchemaVersion, r.lastModAt); err != nil {
+			return
+		}
+	}
+
+	renameSQL := fmt.Sprintf("RENAME TABLE %s TO %s, %s TO %s", tableName, oldTable, shadowTable, tableName)
+	_, err = conn.ExecContext(ctx, renameSQL)
+	return
+}
+
+// checkSchemaVersion refuses to proceed if tableName contains a row at a
+// schema_version not in want, since migrate wouldn't know how to read or
+// rewrite its columns.
+func checkSchemaVersion(db *sql.DB, tableName string, want ...int64) error {
+	rows, err := db.Query(fmt.Sprintf("SELECT DISTINCT `schema_version` FROM %s", tableName))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return err
+		}
+		if !containsVersion(want, version) {
+			return fmt.Errorf("migrate: unrecognized schema version %d, expected one of %v", version, want)
+		}
+	}
+	return rows.Err()
+}
+
+func containsVersion(versions []int64, version int64) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+package generator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/gaemma/logging"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// MysqlReplicaConfig describes how to tail the primary's binlog instead of
+// talking to its table directly. Setting this on a MysqlConfig switches the
+// backend into read-only replica mode.
+type MysqlReplicaConfig struct {
+	Host       string
+	Port       uint16
+	User       string
+	Password   string
+	ServerID   uint32
+	BinlogFile string
+	BinlogPos  uint32
+}
+
+// ErrReplicaReadOnly is returned by a replica's Next, which never allocates
+// ids itself.
+var ErrReplicaReadOnly = errors.New("replica does not accept writes")
+
+// newMysqlReplicaGenerator snapshots the counter table once, then tails the
+// primary's binlog to keep every key's value up to date in memory.
+func newMysqlReplicaGenerator(config MysqlConfig, logger logging.Logger) (generator Generator, err error) {
+	db, err := sql.Open("mysql", config.Dsn)
+	if err != nil {
+		return
+	}
+	err = db.Ping()
+	if err != nil {
+		return
+	}
+
+	if logger == nil {
+		logger = logging.NewNopLogger()
+	}
+
+	g := new(mysqlReplicaGenerator)
+	g.db = db
+	g.config = config
+	g.counters = make(map[string]int64)
+	g.logger = logger
+
+	if err = g.snapshot(); err != nil {
+		return
+	}
+
+	g.syncer = replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+		ServerID: config.Replica.ServerID,
+		Flavor:   "mysql",
+		Host:     config.Replica.Host,
+		Port:     config.Replica.Port,
+		User:     config.Replica.User,
+		Password: config.Replica.Password,
+	})
+
+	g.position = mysql.Position{Name: config.Replica.BinlogFile, Pos: config.Replica.BinlogPos}
+	streamer, err := g.syncer.StartSync(g.position)
+	if err != nil {
+		return
+	}
+
+	go g.tail(streamer)
+
+	generator = g
+	return
+}
+
+type mysqlReplicaGenerator struct {
+	sync.RWMutex
+	db       *sql.DB
+	config   MysqlConfig
+	counters map[string]int64
+	position mysql.Position
+	syncer   *replication.BinlogSyncer
+	logger   logging.Logger
+}
+
+func (g *mysqlReplicaGenerator) EnableKeys(keys []string) error {
+	// a replica tails every row of the table, so there is nothing to set up
+	// per key beyond the snapshot already taken in newMysqlReplicaGenerator.
+	return nil
+}
+
+func (g *mysqlReplicaGenerator) Next(string) (int64, error) {
+	return 0, ErrReplicaReadOnly
+}
+
+// Current returns the `value` last observed from the binlog, i.e. the
+// reserved window ceiling a primary node is currently allocating `Next` ids
+// out of, not the last id it has actually handed out. A replica's GET can
+// therefore read higher than the primary's GET for the same key; this is
+// intentional, since it's meant to guide a newly promoted node to a safe
+// floor, but callers wanting the exact last-issued id should ask the
+// primary instead.
+func (g *mysqlReplicaGenerator) Current(key string) (id int64, err error) {
+	g.RLock()
+	defer g.RUnlock()
+	id, exist := g.counters[key]
+	if !exist {
+		err = ErrKeyDoesNotExist
+	}
+	return
+}
+
+func (g *mysqlReplicaGenerator) NextN(string, int64) (start, end int64, err error) {
+	return 0, 0, ErrReplicaReadOnly
+}
+
+func (g *mysqlReplicaGenerator) Reset(string, int64) error {
+	return ErrReplicaReadOnly
+}
+
+func (g *mysqlReplicaGenerator) Keys() []string {
+	g.RLock()
+	defer g.RUnlock()
+	keys := make([]string, 0, len(g.counters))
+	for key := range g.counters {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (g *mysqlReplicaGenerator) Enable(string) error {
+	// a replica already tails every row of the table, so there is nothing
+	// extra to enable.
+	return nil
+}
+
+func (g *mysqlReplicaGenerator) Disable(string) error {
+	return ErrReplicaReadOnly
+}
+
+func (g *mysqlReplicaGenerator) Close() error {
+	g.syncer.Close()
+	return g.db.Close()
+}
+
+// Status reports the last binlog position applied, so health checkers can
+// verify replication lag against the primary.
+func (g *mysqlReplicaGenerator) Status() (map[string]string, error) {
+	g.RLock()
+	defer g.RUnlock()
+	return map[string]string{
+		"role":        "replica",
+		"binlog_file": g.position.Name,
+		"binlog_pos":  strconv.FormatUint(uint64(g.position.Pos), 10),
+	}, nil
+}
+
+func (g *mysqlReplicaGenerator) snapshot() error {
+	rows, err := g.db.Query(fmt.Sprintf("SELECT `key`, `value` FROM %s", g.config.TableName))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	counters := make(map[string]int64)
+	for rows.Next() {
+		var key string
+		var value int64
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		counters[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	g.Lock()
+	defer g.Unlock()
+	g.counters = counters
+	return nil
+}
+
+// tail applies row events for the configured table as they arrive, keeping
+// g.counters and g.position current until the stream errors out.
+func (g *mysqlReplicaGenerator) tail(streamer *replication.BinlogStreamer) {
+	var table *replication.TableMapEvent
+	for {
+		ev, err := streamer.GetEvent(context.Background())
+		if err != nil {
+			g.logger.Warning("replica stream for \"%s\" stopped: %s", g.config.TableName, err.Error())
+			return
+		}
+
+		switch e := ev.Event.(type) {
+		case *replication.TableMapEvent:
+			if string(e.Table) == g.config.TableName {
+				table = e
+			} else {
+				table = nil
+			}
+		case *replication.RowsEvent:
+			if table != nil && string(e.Table.Table) == g.config.TableName {
+				g.applyRows(e)
+			}
+		case *replication.RotateEvent:
+			g.Lock()
+			g.position = mysql.Position{Name: string(e.NextLogName), Pos: uint32(e.Position)}
+			g.Unlock()
+		}
+
+		if ev.Header != nil {
+			g.Lock()
+			g.position.Pos = ev.Header.LogPos
+			g.Unlock()
+		}
+	}
+}
+
+func (g *mysqlReplicaGenerator) applyRows(e *replication.RowsEvent) {
+	g.Lock()
+	defer g.Unlock()
+	for _, row := range e.Rows {
+		if len(row) < 3 {
+			continue
+		}
+		key, ok := asString(row[1])
+		if !ok {
+			g.logger.Warning("replica for \"%s\" skipped a row with unexpected key column type %T", g.config.TableName, row[1])
+			continue
+		}
+		value, err := asInt64(row[2])
+		if err != nil {
+			g.logger.Warning("replica for \"%s\" skipped a row: %s", g.config.TableName, err.Error())
+			continue
+		}
+		g.counters[key] = value
+	}
+}
+
+// asString handles both encodings go-mysql uses for VARCHAR columns
+// depending on driver/version: a plain string, or the raw []byte the wire
+// protocol actually carries.
+func asString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case []byte:
+		return string(s), true
+	default:
+		return "", false
+	}
+}
+
+func asInt64(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int64:
+		return n, nil
+	case int32:
+		return int64(n), nil
+	case uint64:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	default:
+		return 0, fmt.Errorf("unsupported column type: %T", v)
+	}
+}
@@ -0,0 +1,313 @@
+package generator
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gaemma/logging"
+	_ "github.com/lib/pq"
+)
+
+const (
+	pgSelectSQL = `SELECT "value" FROM %s WHERE "key" = $1 FOR UPDATE`
+	pgUpsertSQL = `INSERT INTO %s ("key", "value", "last_mod_at") VALUES ($1, $2, $3)
+		ON CONFLICT ("key") DO UPDATE SET "value" = $2, "last_mod_at" = $3`
+	pgCreateTableSQL = `CREATE TABLE %s (
+	"id" SERIAL PRIMARY KEY,
+	"key" VARCHAR(32) NOT NULL UNIQUE,
+	"value" BIGINT NOT NULL,
+	"last_mod_at" BIGINT NOT NULL
+)`
+)
+
+// PostgresConfig contains the parameters needed by the generator.
+type PostgresConfig struct {
+	Dsn       string
+	TableName string
+	Step      int64
+}
+
+func init() {
+	Register("postgres", postgresFactory, postgresInitializer)
+}
+
+func postgresFactory(meta toml.MetaData, primitive toml.Primitive, logger logging.Logger) (Generator, error) {
+	var config PostgresConfig
+	if err := meta.PrimitiveDecode(primitive, &config); err != nil {
+		return nil, err
+	}
+	return NewPostgresGenerator(config, logger)
+}
+
+func postgresInitializer(meta toml.MetaData, primitive toml.Primitive) error {
+	var config PostgresConfig
+	if err := meta.PrimitiveDecode(primitive, &config); err != nil {
+		return err
+	}
+	return InitPostgresGenerator(config)
+}
+
+// InitPostgresGenerator initializes the table needed.
+func InitPostgresGenerator(config PostgresConfig) (err error) {
+	db, err := sql.Open("postgres", config.Dsn)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+	_, err = db.Exec(fmt.Sprintf(pgCreateTableSQL, config.TableName))
+	return
+}
+
+// NewPostgresGenerator creates a postgresql id generator.
+func NewPostgresGenerator(config PostgresConfig, logger logging.Logger) (generator Generator, err error) {
+	db, err := sql.Open("postgres", config.Dsn)
+	if err != nil {
+		return
+	}
+	err = db.Ping()
+	if err != nil {
+		return
+	}
+
+	g := new(postgresGenerator)
+	g.sourceMap = make(map[string]*postgresRowBasedEngine)
+	g.db = db
+	g.config = config
+	g.skip = config.Step
+	if logger == nil {
+		logger = logging.NewNopLogger()
+	}
+	g.logger = logger
+	generator = g
+	return
+}
+
+type postgresGenerator struct {
+	sync.RWMutex
+	sourceMap map[string]*postgresRowBasedEngine
+	db        *sql.DB
+	config    PostgresConfig
+	skip      int64
+	logger    logging.Logger
+}
+
+func (p *postgresGenerator) EnableKeys(keys []string) (err error) {
+	data := make(map[string]*postgresRowBasedEngine, len(keys))
+	for _, key := range keys {
+		data[key], err = newPostgresRowBasedEngine(p, key, p.skip, p.logger)
+		if err != nil {
+			return
+		}
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	p.sourceMap = data
+	return
+}
+
+func (p *postgresGenerator) Next(key string) (id int64, err error) {
+	engine, err := p.rowBasedEngine(key)
+	if err != nil {
+		return
+	}
+	return engine.next()
+}
+
+func (p *postgresGenerator) Current(key string) (id int64, err error) {
+	engine, err := p.rowBasedEngine(key)
+	if err != nil {
+		return
+	}
+	return engine.current()
+}
+
+func (p *postgresGenerator) NextN(key string, n int64) (start, end int64, err error) {
+	engine, err := p.rowBasedEngine(key)
+	if err != nil {
+		return
+	}
+	return engine.nextN(n)
+}
+
+func (p *postgresGenerator) Reset(key string, value int64) error {
+	engine, err := p.rowBasedEngine(key)
+	if err != nil {
+		return err
+	}
+	return engine.reset(value)
+}
+
+func (p *postgresGenerator) Keys() []string {
+	p.RLock()
+	defer p.RUnlock()
+	keys := make([]string, 0, len(p.sourceMap))
+	for key := range p.sourceMap {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (p *postgresGenerator) Enable(key string) (err error) {
+	engine, err := newPostgresRowBasedEngine(p, key, p.skip, p.logger)
+	if err != nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.sourceMap[key] = engine
+	return
+}
+
+func (p *postgresGenerator) Disable(key string) error {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.sourceMap, key)
+	return nil
+}
+
+func (p *postgresGenerator) Close() error {
+	return p.db.Close()
+}
+
+func (p *postgresGenerator) rowBasedEngine(key string) (engine *postgresRowBasedEngine, err error) {
+	p.RLock()
+	defer p.RUnlock()
+	engine, exist := p.sourceMap[key]
+	if !exist {
+		err = ErrKeyDoesNotExist
+	}
+	return
+}
+
+func newPostgresRowBasedEngine(generator *postgresGenerator, key string, skip int64, logger logging.Logger) (engine *postgresRowBasedEngine, err error) {
+	if skip <= 0 {
+		err = fmt.Errorf("invalid skip: %d", skip)
+		return
+	}
+
+	pgEngine := new(postgresRowBasedEngine)
+	pgEngine.generator = generator
+	pgEngine.selectSQL = fmt.Sprintf(pgSelectSQL, generator.config.TableName)
+	pgEngine.upsertSQL = fmt.Sprintf(pgUpsertSQL, generator.config.TableName)
+	pgEngine.skip = skip
+	pgEngine.key = key
+	if logger == nil {
+		logger = logging.NewNopLogger()
+	}
+	pgEngine.logger = logger
+	logger.Info("initialize counter for key \"%s\".", key)
+	pgEngine.cur, pgEngine.max, err = pgEngine.increase(skip)
+	if err != nil {
+		return
+	}
+
+	return pgEngine, err
+}
+
+type postgresRowBasedEngine struct {
+	generator *postgresGenerator
+	selectSQL string
+	upsertSQL string
+	key       string
+	skip      int64
+	max       int64
+	cur       int64
+	mutex     sync.Mutex
+	logger    logging.Logger
+}
+
+func (p *postgresRowBasedEngine) next() (id int64, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.cur == p.max {
+		p.logger.Info("increase counter for key: \"%s\"", p.key)
+		p.cur, p.max, err = p.increase(p.skip)
+		if err != nil {
+			return
+		}
+	}
+	p.cur++
+	return p.cur, nil
+}
+
+func (p *postgresRowBasedEngine) current() (int64, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.cur, nil
+}
+
+// nextN allocates n consecutive ids in one go, bumping the window by n*skip
+// when the current window can't satisfy the request.
+func (p *postgresRowBasedEngine) nextN(n int64) (start, end int64, err error) {
+	if n <= 0 {
+		err = fmt.Errorf("invalid count: %d", n)
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.max-p.cur < n {
+		p.logger.Info("increase counter for key: \"%s\"", p.key)
+		p.cur, p.max, err = p.increase(n * p.skip)
+		if err != nil {
+			return
+		}
+	}
+	start = p.cur + 1
+	end = p.cur + n
+	p.cur = end
+	return
+}
+
+func (p *postgresRowBasedEngine) reset(value int64) (err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	// the stored value is the last id handed out, so the next id is value.
+	_, err = p.generator.db.Exec(p.upsertSQL, p.key, value-1, time.Now().Unix())
+	if err != nil {
+		return
+	}
+	p.cur = value - 1
+	p.max = value - 1
+	return
+}
+
+// increase mirrors mysqlRowBasedEngine.increase: read the current value
+// under a row lock inside a transaction, then upsert the new window in the
+// same transaction so a torn read never hands out a stale window.
+func (p *postgresRowBasedEngine) increase(delta int64) (cur, max int64, err error) {
+	defer func() {
+		if err == nil {
+			p.logger.Info("counter for key \"%s\" is increased from %d to %d.", p.key, cur, max)
+		}
+	}()
+	tx, err := p.generator.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			newErr := tx.Rollback()
+			if newErr != nil {
+				err = newErr
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	err = tx.QueryRow(p.selectSQL, p.key).Scan(&cur)
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	if err == sql.ErrNoRows {
+		cur = 0
+	}
+
+	max = cur + delta
+	_, err = tx.Exec(p.upsertSQL, p.key, max, time.Now().Unix())
+	return
+}
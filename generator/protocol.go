@@ -13,4 +13,23 @@ type Generator interface {
 	EnableKeys([]string) error
 	Next(string) (int64, error)
 	Current(string) (int64, error)
+
+	// NextN atomically allocates n consecutive ids for key, returning the
+	// first and last id of the allocated range.
+	NextN(key string, n int64) (start, end int64, err error)
+	// Reset sets key's next id to value.
+	Reset(key string, value int64) error
+	// Keys lists the keys currently enabled on this generator.
+	Keys() []string
+	// Enable starts serving key without requiring a restart.
+	Enable(key string) error
+	// Disable stops serving key without requiring a restart.
+	Disable(key string) error
+}
+
+// StatusReporter is implemented by generators that can report internal
+// status information, such as replication lag, beyond the basic Generator
+// contract.
+type StatusReporter interface {
+	Status() (map[string]string, error)
 }
@@ -0,0 +1,267 @@
+package generator
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gaemma/logging"
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisConfig contains the parameters needed by the redis generator.
+type RedisConfig struct {
+	Addr string
+	Step int64
+}
+
+func init() {
+	Register("redis", redisFactory, redisInitializer)
+}
+
+func redisFactory(meta toml.MetaData, primitive toml.Primitive, logger logging.Logger) (Generator, error) {
+	var config RedisConfig
+	if err := meta.PrimitiveDecode(primitive, &config); err != nil {
+		return nil, err
+	}
+	return NewRedisGenerator(config, logger)
+}
+
+func redisInitializer(meta toml.MetaData, primitive toml.Primitive) error {
+	// redis keys are created lazily by INCRBY, there is nothing to provision.
+	return nil
+}
+
+// NewRedisGenerator creates a redis id generator.
+func NewRedisGenerator(config RedisConfig, logger logging.Logger) (generator Generator, err error) {
+	if config.Step <= 0 {
+		err = fmt.Errorf("invalid step: %d", config.Step)
+		return
+	}
+
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", config.Addr)
+		},
+	}
+	conn := pool.Get()
+	defer conn.Close()
+	if _, err = conn.Do("PING"); err != nil {
+		return
+	}
+
+	g := new(redisGenerator)
+	g.pool = pool
+	g.sourceMap = make(map[string]*redisRowBasedEngine)
+	g.skip = config.Step
+	if logger == nil {
+		logger = logging.NewNopLogger()
+	}
+	g.logger = logger
+	generator = g
+	return
+}
+
+type redisGenerator struct {
+	sync.RWMutex
+	pool      *redis.Pool
+	sourceMap map[string]*redisRowBasedEngine
+	skip      int64
+	logger    logging.Logger
+}
+
+func (g *redisGenerator) EnableKeys(keys []string) (err error) {
+	data := make(map[string]*redisRowBasedEngine, len(keys))
+	for _, key := range keys {
+		data[key], err = newRedisRowBasedEngine(g, key, g.skip, g.logger)
+		if err != nil {
+			return
+		}
+	}
+	g.Lock()
+	defer g.Unlock()
+
+	g.sourceMap = data
+	return
+}
+
+func (g *redisGenerator) Next(key string) (id int64, err error) {
+	engine, err := g.rowBasedEngine(key)
+	if err != nil {
+		return
+	}
+	return engine.next()
+}
+
+func (g *redisGenerator) Current(key string) (id int64, err error) {
+	engine, err := g.rowBasedEngine(key)
+	if err != nil {
+		return
+	}
+	return engine.current()
+}
+
+func (g *redisGenerator) NextN(key string, n int64) (start, end int64, err error) {
+	engine, err := g.rowBasedEngine(key)
+	if err != nil {
+		return
+	}
+	return engine.nextN(n)
+}
+
+func (g *redisGenerator) Reset(key string, value int64) error {
+	engine, err := g.rowBasedEngine(key)
+	if err != nil {
+		return err
+	}
+	return engine.reset(value)
+}
+
+func (g *redisGenerator) Keys() []string {
+	g.RLock()
+	defer g.RUnlock()
+	keys := make([]string, 0, len(g.sourceMap))
+	for key := range g.sourceMap {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (g *redisGenerator) Enable(key string) (err error) {
+	engine, err := newRedisRowBasedEngine(g, key, g.skip, g.logger)
+	if err != nil {
+		return
+	}
+	g.Lock()
+	defer g.Unlock()
+	g.sourceMap[key] = engine
+	return
+}
+
+func (g *redisGenerator) Disable(key string) error {
+	g.Lock()
+	defer g.Unlock()
+	delete(g.sourceMap, key)
+	return nil
+}
+
+func (g *redisGenerator) Close() error {
+	return g.pool.Close()
+}
+
+func (g *redisGenerator) rowBasedEngine(key string) (engine *redisRowBasedEngine, err error) {
+	g.RLock()
+	defer g.RUnlock()
+	engine, exist := g.sourceMap[key]
+	if !exist {
+		err = ErrKeyDoesNotExist
+	}
+	return
+}
+
+func newRedisRowBasedEngine(generator *redisGenerator, key string, skip int64, logger logging.Logger) (engine *redisRowBasedEngine, err error) {
+	if skip <= 0 {
+		err = fmt.Errorf("invalid skip: %d", skip)
+		return
+	}
+
+	redisEngine := new(redisRowBasedEngine)
+	redisEngine.generator = generator
+	redisEngine.key = key
+	redisEngine.skip = skip
+	if logger == nil {
+		logger = logging.NewNopLogger()
+	}
+	redisEngine.logger = logger
+	logger.Info("initialize counter for key \"%s\".", key)
+	redisEngine.cur, redisEngine.max, err = redisEngine.increase(skip)
+	if err != nil {
+		return
+	}
+
+	return redisEngine, err
+}
+
+// redisRowBasedEngine mirrors mysqlRowBasedEngine's window batching, but
+// fetches a new window with a single INCRBY instead of a row lock.
+type redisRowBasedEngine struct {
+	generator *redisGenerator
+	key       string
+	skip      int64
+	max       int64
+	cur       int64
+	mutex     sync.Mutex
+	logger    logging.Logger
+}
+
+func (e *redisRowBasedEngine) next() (id int64, err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.cur == e.max {
+		e.logger.Info("increase counter for key: \"%s\"", e.key)
+		e.cur, e.max, err = e.increase(e.skip)
+		if err != nil {
+			return
+		}
+	}
+	e.cur++
+	return e.cur, nil
+}
+
+// nextN allocates n consecutive ids in one go, bumping the window by n*skip
+// when the current window can't satisfy the request.
+func (e *redisRowBasedEngine) nextN(n int64) (start, end int64, err error) {
+	if n <= 0 {
+		err = fmt.Errorf("invalid count: %d", n)
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.max-e.cur < n {
+		e.logger.Info("increase counter for key: \"%s\"", e.key)
+		e.cur, e.max, err = e.increase(n * e.skip)
+		if err != nil {
+			return
+		}
+	}
+	start = e.cur + 1
+	end = e.cur + n
+	e.cur = end
+	return
+}
+
+func (e *redisRowBasedEngine) reset(value int64) (err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	conn := e.generator.pool.Get()
+	defer conn.Close()
+	// the stored value is the last id handed out, so the next id is value.
+	if _, err = conn.Do("SET", e.key, value-1); err != nil {
+		return
+	}
+	e.cur = value - 1
+	e.max = value - 1
+	return
+}
+
+func (e *redisRowBasedEngine) current() (int64, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.cur, nil
+}
+
+func (e *redisRowBasedEngine) increase(delta int64) (cur, max int64, err error) {
+	defer func() {
+		if err == nil {
+			e.logger.Info("counter for key \"%s\" is increased from %d to %d.", e.key, cur, max)
+		}
+	}()
+	conn := e.generator.pool.Get()
+	defer conn.Close()
+	max, err = redis.Int64(conn.Do("INCRBY", e.key, delta))
+	if err != nil {
+		return
+	}
+	cur = max - delta
+	return
+}
@@ -0,0 +1,58 @@
+package generator
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gaemma/logging"
+)
+
+// Factory builds a Generator from its backend-specific TOML section, e.g.
+// the `[backend.mysql]` table. meta is the MetaData of the whole config file
+// and is needed to decode primitive into a concrete struct.
+type Factory func(meta toml.MetaData, primitive toml.Primitive, logger logging.Logger) (Generator, error)
+
+// Initializer prepares the storage backend (tables, keyspaces, ...)
+// described by the backend-specific TOML section.
+type Initializer func(meta toml.MetaData, primitive toml.Primitive) error
+
+type registration struct {
+	factory     Factory
+	initializer Initializer
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]registration)
+)
+
+// Register makes a backend available under name. It is meant to be called
+// from the init function of the package implementing the backend.
+func Register(name string, factory Factory, initializer Initializer) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry[name] = registration{factory: factory, initializer: initializer}
+}
+
+// New creates the Generator registered under name.
+func New(name string, meta toml.MetaData, primitive toml.Primitive, logger logging.Logger) (Generator, error) {
+	registryMutex.RLock()
+	r, exist := registry[name]
+	registryMutex.RUnlock()
+	if !exist {
+		return nil, fmt.Errorf("unknown backend: %s", name)
+	}
+	return r.factory(meta, primitive, logger)
+}
+
+// Init prepares the backend registered under name.
+func Init(name string, meta toml.MetaData, primitive toml.Primitive) error {
+	registryMutex.RLock()
+	r, exist := registry[name]
+	registryMutex.RUnlock()
+	if !exist {
+		return fmt.Errorf("unknown backend: %s", name)
+	}
+	return r.initializer(meta, primitive)
+}